@@ -0,0 +1,101 @@
+// Package aws provides core functionality for working with AWS services.
+package aws
+
+import (
+	"errors"
+	"os"
+)
+
+// Auth holds the AWS credentials used to sign requests.
+type Auth struct {
+	AccessKey, SecretKey string
+	Token                string
+}
+
+// EnvAuth creates an Auth based on environment information, as laid
+// out in the AWS Go authentication guide:
+//
+//	AWS_ACCESS_KEY_ID or AWS_ACCESS_KEY
+//	AWS_SECRET_ACCESS_KEY or AWS_SECRET_KEY
+func EnvAuth() (auth Auth, err error) {
+	auth.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+	if auth.AccessKey == "" {
+		auth.AccessKey = os.Getenv("AWS_ACCESS_KEY")
+	}
+
+	auth.SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if auth.SecretKey == "" {
+		auth.SecretKey = os.Getenv("AWS_SECRET_KEY")
+	}
+
+	if auth.AccessKey == "" {
+		err = errors.New("AWS_ACCESS_KEY_ID or AWS_ACCESS_KEY not found in environment")
+	}
+	if auth.SecretKey == "" {
+		err = errors.New("AWS_SECRET_ACCESS_KEY or AWS_SECRET_KEY not found in environment")
+	}
+	return
+}
+
+// Region defines the URLs where AWS services may be accessed.
+//
+// See http://docs.amazonwebservices.com/general/latest/gr/rande.html for
+// more details.
+type Region struct {
+	Name                 string // the canonical name of this region.
+	S3Endpoint           string
+	S3BucketEndpoint     string // Not needed by AWS S3. Use ${bucket} for bucket name.
+	S3LocationConstraint bool   // true if this region requires a LocationConstraint declaration.
+	S3LowercaseBucket    bool   // true if the region requires bucket names to be lower case.
+
+	// Sign is the signature version a client targeting this region
+	// should default to. It is honoured by s3.New, which sets
+	// S3.Signature from it; a client constructed directly, or one that
+	// sets S3.Signature itself, overrides it as usual.
+	Sign SignatureVersion
+}
+
+// NewRegion builds a Region for an S3-compatible server (Minio, Ceph
+// RGW, Riak CS, LocalStack, ...) that isn't one of the well-known AWS
+// regions below. Such servers almost always require SigV4 and virtual-
+// hosted-style addressing is rarely workable for them (no wildcard TLS
+// cert for every bucket subdomain), so callers combine this with
+// s3.S3.PathStyle = true.
+func NewRegion(name, endpoint string) Region {
+	return Region{
+		Name:       name,
+		S3Endpoint: endpoint,
+		Sign:       V4Signature,
+	}
+}
+
+var USEast = Region{
+	Name:                 "us-east-1",
+	S3Endpoint:           "https://s3.amazonaws.com",
+	S3LocationConstraint: false,
+}
+
+var USWest = Region{
+	Name:                 "us-west-1",
+	S3Endpoint:           "https://s3-us-west-1.amazonaws.com",
+	S3LocationConstraint: true,
+}
+
+var EUWest = Region{
+	Name:                 "eu-west-1",
+	S3Endpoint:           "https://s3-eu-west-1.amazonaws.com",
+	S3LocationConstraint: true,
+}
+
+var APSoutheast = Region{
+	Name:                 "ap-southeast-1",
+	S3Endpoint:           "https://s3-ap-southeast-1.amazonaws.com",
+	S3LocationConstraint: true,
+}
+
+var Regions = map[string]Region{
+	USEast.Name:      USEast,
+	USWest.Name:      USWest,
+	EUWest.Name:      EUWest,
+	APSoutheast.Name: APSoutheast,
+}
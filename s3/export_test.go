@@ -0,0 +1,13 @@
+package s3
+
+// SetListPartsMax lets tests exercise ListParts' paging logic without
+// having to upload thousands of parts.
+func SetListPartsMax(n int) {
+	listPartsMax = n
+}
+
+// SetListMultiMax lets tests exercise ListMulti's paging logic without
+// having to start thousands of multipart uploads.
+func SetListMultiMax(n int) {
+	listMultiMax = n
+}
@@ -0,0 +1,359 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/czos/goamz/aws"
+)
+
+// Multi represents an unfinished multipart upload.
+//
+// See https://docs.aws.amazon.com/AmazonS3/latest/dev/uploadobjusingmpu.html
+// for more details on the S3 multipart upload protocol.
+type Multi struct {
+	Bucket   *Bucket
+	Key      string
+	UploadId string
+
+	// Options is the Options the upload was initiated with. PutPart
+	// resends its SSE-C customer key headers (if any) on every part,
+	// since S3 requires them on each part of an SSE-C object, not just
+	// on InitMulti.
+	Options Options
+}
+
+// listPartsMax and listMultiMax are the default max-parts/max-uploads
+// page sizes used when paging through ListParts and ListMulti. They are
+// adjustable via SetListPartsMax/SetListMultiMax (see export_test.go) so
+// tests can exercise the paging logic without uploading thousands of
+// parts.
+var listPartsMax = 1000
+var listMultiMax = 1000
+
+// InitMulti starts a new multipart upload of the given key.
+func (b *Bucket) InitMulti(key string, contType string, perm ACL) (*Multi, error) {
+	return b.initMulti(context.Background(), key, contType, perm, Options{})
+}
+
+// InitMultiWithContext is like InitMulti but aborts the request if ctx
+// is cancelled or its deadline expires.
+func (b *Bucket) InitMultiWithContext(ctx context.Context, key string, contType string, perm ACL) (*Multi, error) {
+	return b.initMulti(ctx, key, contType, perm, Options{})
+}
+
+// InitMultiWithOptions is like InitMulti but takes Options, most notably
+// for server-side encryption: the same SSE-C customer key (if any) must
+// then be passed to every PutPart, which Multi.Options makes automatic.
+func (b *Bucket) InitMultiWithOptions(key string, contType string, perm ACL, options Options) (*Multi, error) {
+	return b.initMulti(context.Background(), key, contType, perm, options)
+}
+
+func (b *Bucket) initMulti(ctx context.Context, key string, contType string, perm ACL, options Options) (*Multi, error) {
+	headers := map[string][]string{
+		"Content-Type": {contType},
+		"x-amz-acl":    {string(perm)},
+	}
+	options.addHeaders(headers)
+	params := urlValues("uploads", "")
+	req := &request{
+		method:  "POST",
+		bucket:  b.Name,
+		path:    key,
+		params:  params,
+		headers: headers,
+		ctx:     ctx,
+	}
+	var resp struct {
+		UploadId string `xml:"UploadId"`
+	}
+	err := b.S3.query(req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return &Multi{Bucket: b, Key: key, UploadId: resp.UploadId, Options: options}, nil
+}
+
+// Part represents a single part in a multipart upload.
+type Part struct {
+	N    int // part number
+	ETag string
+	Size int64
+}
+
+type partList []Part
+
+func (l partList) Len() int           { return len(l) }
+func (l partList) Less(i, j int) bool { return l[i].N < l[j].N }
+func (l partList) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+
+// PutPart sends part N of the multipart upload. Under aws.V4Signature it
+// is streamed using chunked SigV4 signing rather than buffered in memory;
+// under aws.V2Signature it's read into memory up front to attach a
+// Content-MD5, S3's only other way to verify part integrity.
+func (m *Multi) PutPart(n int, r io.ReadSeeker) (Part, error) {
+	return m.PutPartWithContext(context.Background(), n, r)
+}
+
+// PutPartWithContext is like PutPart but aborts the request if ctx is
+// cancelled or its deadline expires.
+func (m *Multi) PutPartWithContext(ctx context.Context, n int, r io.ReadSeeker) (Part, error) {
+	partSize, _, err := seekerInfo(r)
+	if err != nil {
+		return Part{}, err
+	}
+	return m.putPart(ctx, n, r, partSize)
+}
+
+func seekerInfo(r io.ReadSeeker) (size int64, pos int64, err error) {
+	pos, err = r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, 0, err
+	}
+	_, err = r.Seek(pos, io.SeekStart)
+	if err != nil {
+		return 0, 0, err
+	}
+	return end - pos, pos, nil
+}
+
+// putPart sends part N of the multipart upload. Under aws.V4Signature it
+// streams the part straight from r using the chunked SigV4 streaming
+// signature, so large parts don't have to be buffered in memory; under
+// aws.V2Signature, which has no equivalent per-chunk authentication, it
+// buffers the part to attach a Content-MD5 instead.
+func (m *Multi) putPart(ctx context.Context, n int, r io.ReadSeeker, partSize int64) (Part, error) {
+	streamingV4 := m.Bucket.S3.Signature == aws.V4Signature
+
+	headers := map[string][]string{
+		"Content-Length": {strconv.FormatInt(partSize, 10)},
+	}
+	m.Options.addSSEHeaders(headers)
+
+	var payload io.Reader = io.LimitReader(r, partSize)
+	var payloadHash string
+	if !streamingV4 {
+		data, err := ioutil.ReadAll(payload)
+		if err != nil {
+			return Part{}, err
+		}
+		sum := md5.Sum(data)
+		headers["Content-MD5"] = []string{base64.StdEncoding.EncodeToString(sum[:])}
+		payloadHash = aws.Sha256Hex(data)
+		payload = bytes.NewReader(data)
+	}
+
+	params := urlValues("partNumber", strconv.Itoa(n), "uploadId", m.UploadId)
+	req := &request{
+		method:      "PUT",
+		bucket:      m.Bucket.Name,
+		path:        m.Key,
+		params:      params,
+		headers:     headers,
+		payload:     payload,
+		payloadHash: payloadHash,
+		streamingV4: streamingV4,
+		ctx:         ctx,
+	}
+	err := m.Bucket.S3.prepare(req)
+	if err != nil {
+		return Part{}, err
+	}
+	resp, err := m.Bucket.S3.run(req, nil)
+	if err != nil {
+		return Part{}, err
+	}
+	etag := resp.Header.Get("ETag")
+	resp.Body.Close()
+	return Part{N: n, Size: partSize, ETag: etag}, nil
+}
+
+// Complete assembles the given, previously uploaded parts into the
+// final object. Parts are reordered by part number before sending, since
+// S3 requires them in ascending order regardless of upload order.
+func (m *Multi) Complete(parts []Part) error {
+	return m.CompleteWithContext(context.Background(), parts)
+}
+
+// CompleteWithContext is like Complete but aborts the request if ctx is
+// cancelled or its deadline expires.
+func (m *Multi) CompleteWithContext(ctx context.Context, parts []Part) error {
+	ordered := append(partList(nil), parts...)
+	sort.Sort(ordered)
+
+	var buf bytes.Buffer
+	buf.WriteString("<CompleteMultipartUpload>")
+	for _, p := range ordered {
+		fmt.Fprintf(&buf, "<Part><PartNumber>%d</PartNumber><ETag>%s</ETag></Part>", p.N, p.ETag)
+	}
+	buf.WriteString("</CompleteMultipartUpload>")
+
+	params := urlValues("uploadId", m.UploadId)
+	req := &request{
+		method:  "POST",
+		bucket:  m.Bucket.Name,
+		path:    m.Key,
+		params:  params,
+		payload: bytes.NewReader(buf.Bytes()),
+		ctx:     ctx,
+	}
+	return m.Bucket.S3.query(req, nil)
+}
+
+// Abort cancels a multipart upload, freeing any uploaded parts.
+func (m *Multi) Abort() error {
+	return m.AbortWithContext(context.Background())
+}
+
+// AbortWithContext is like Abort but aborts the request itself if ctx is
+// cancelled or its deadline expires.
+func (m *Multi) AbortWithContext(ctx context.Context) error {
+	params := urlValues("uploadId", m.UploadId)
+	req := &request{
+		method: "DELETE",
+		bucket: m.Bucket.Name,
+		path:   m.Key,
+		params: params,
+		ctx:    ctx,
+	}
+	return m.Bucket.S3.query(req, nil)
+}
+
+// ListParts lists the parts already uploaded for this multipart upload,
+// paging through the results (at most listPartsMax per request).
+func (m *Multi) ListParts() ([]Part, error) {
+	var parts []Part
+	marker := ""
+	for {
+		params := urlValues("uploadId", m.UploadId, "max-parts", strconv.Itoa(listPartsMax))
+		if marker != "" {
+			params.Set("part-number-marker", marker)
+		}
+		req := &request{
+			method: "GET",
+			bucket: m.Bucket.Name,
+			path:   m.Key,
+			params: params,
+		}
+		var resp struct {
+			Part []struct {
+				PartNumber int    `xml:"PartNumber"`
+				ETag       string `xml:"ETag"`
+				Size       int64  `xml:"Size"`
+			} `xml:"Part"`
+			IsTruncated          bool   `xml:"IsTruncated"`
+			NextPartNumberMarker string `xml:"NextPartNumberMarker"`
+		}
+		err := m.Bucket.S3.query(req, &resp)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range resp.Part {
+			parts = append(parts, Part{N: p.PartNumber, ETag: p.ETag, Size: p.Size})
+		}
+		if !resp.IsTruncated {
+			break
+		}
+		marker = resp.NextPartNumberMarker
+	}
+	return parts, nil
+}
+
+// ListMulti returns the in-progress multipart uploads in the bucket,
+// optionally filtered by prefix and delimiter.
+func (b *Bucket) ListMulti(prefix, delim string) (multis []*Multi, prefixes []string, err error) {
+	return b.ListMultiWithContext(context.Background(), prefix, delim)
+}
+
+// ListMultiWithContext is like ListMulti but aborts the request,
+// including any further pages, as soon as ctx is cancelled or its
+// deadline expires.
+func (b *Bucket) ListMultiWithContext(ctx context.Context, prefix, delim string) (multis []*Multi, prefixes []string, err error) {
+	marker := ""
+	for {
+		params := urlValues("uploads", "", "max-uploads", strconv.Itoa(listMultiMax), "prefix", prefix, "delimiter", delim)
+		if marker != "" {
+			params.Set("key-marker", marker)
+		}
+		req := &request{
+			method: "GET",
+			bucket: b.Name,
+			path:   "/",
+			params: params,
+			ctx:    ctx,
+		}
+		var resp struct {
+			Upload []struct {
+				Key      string `xml:"Key"`
+				UploadId string `xml:"UploadId"`
+			} `xml:"Upload"`
+			CommonPrefixes []string `xml:"CommonPrefixes>Prefix"`
+			IsTruncated    bool     `xml:"IsTruncated"`
+			NextKeyMarker  string   `xml:"NextKeyMarker"`
+		}
+		err = b.S3.query(req, &resp)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, u := range resp.Upload {
+			multis = append(multis, &Multi{Bucket: b, Key: u.Key, UploadId: u.UploadId})
+		}
+		prefixes = append(prefixes, resp.CommonPrefixes...)
+		if !resp.IsTruncated {
+			break
+		}
+		marker = resp.NextKeyMarker
+	}
+	return multis, prefixes, nil
+}
+
+// PutAll reads r sequentially in partSize chunks and uploads each as a
+// part, buffering at most one part in memory at a time. It returns the
+// completed parts, unsorted beyond upload order, for a subsequent call
+// to Complete.
+func (m *Multi) PutAll(r io.Reader, partSize int64) ([]Part, error) {
+	var parts []Part
+	for n := 1; ; n++ {
+		data, err := ioutil.ReadAll(io.LimitReader(r, partSize))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) == 0 && n > 1 {
+			break
+		}
+		part, err := m.putPart(context.Background(), n, bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+		if int64(len(data)) < partSize {
+			break
+		}
+	}
+	return parts, nil
+}
+
+// urlValues builds a url.Values from alternating key/value pairs,
+// skipping empty values except for flag-style params (like "uploads")
+// that are meaningful even with an empty value.
+func urlValues(pairs ...string) url.Values {
+	v := url.Values{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] != "" || pairs[i] == "uploads" {
+			v.Set(pairs[i], pairs[i+1])
+		}
+	}
+	return v
+}
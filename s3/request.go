@@ -0,0 +1,203 @@
+package s3
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/czos/goamz/aws"
+)
+
+var attempts = aws.AttemptStrategy{
+	Min:   5,
+	Total: 5 * time.Second,
+	Delay: 200 * time.Millisecond,
+}
+
+// httpClient disables Go's automatic redirect following: S3's 301
+// PermanentRedirect responses (wrong region/endpoint for a bucket) carry
+// the correct endpoint in the XML body rather than a Location header,
+// and blindly following a redirect would also replay an Authorization
+// header that's no longer valid for the new host.
+var httpClient = &http.Client{
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// request represents an in-flight S3 operation: everything needed to
+// build the HTTP request, sign it, and interpret the response.
+type request struct {
+	method     string
+	bucket     string
+	path       string
+	signpath   string
+	params     url.Values
+	headers    http.Header
+	baseurl    string
+	payload    io.Reader
+	prepared   bool
+	redirected bool
+
+	// ctx, when non-nil, ties this request (and, for a redirected
+	// retry, the retry too) to a caller-supplied context so it can be
+	// cancelled or time out mid-flight.
+	ctx context.Context
+
+	// streamingV4, when the client is configured for aws.V4Signature,
+	// asks signV4 to use the chunked STREAMING-AWS4-HMAC-SHA256-PAYLOAD
+	// mode so payload doesn't need to be buffered up front to compute a
+	// single sha256 over it. payloadHash lets callers that already know
+	// the hash (e.g. a []byte Put) sign it directly instead.
+	streamingV4 bool
+	payloadHash string
+}
+
+// prepare fills in defaults and resolves the bucket's base URL before the
+// request is signed and sent.
+func (s3 *S3) prepare(req *request) error {
+	if req.prepared {
+		return nil
+	}
+	req.prepared = true
+	if req.method == "" {
+		req.method = "GET"
+	}
+	if req.params == nil {
+		req.params = url.Values{}
+	}
+	if req.headers == nil {
+		req.headers = http.Header{}
+	}
+
+	hostname := strings.Replace(s3.Region.S3BucketEndpoint, "${bucket}", req.bucket, -1)
+	if hostname == "" || s3.PathStyle {
+		req.baseurl = s3.Region.S3Endpoint
+		if req.bucket != "" {
+			req.path = "/" + req.bucket + req.path
+		}
+	} else {
+		req.baseurl = hostname
+	}
+	req.signpath = req.path
+	return nil
+}
+
+// url builds the net/url.URL for this request, honouring path-style
+// addressing when requested.
+func (req *request) url(pathStyle bool) (*url.URL, error) {
+	u, err := url.Parse(req.baseurl)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = req.params.Encode()
+	u.Path = req.path
+	return u, nil
+}
+
+// signedURL returns a pre-signed URL for this request, computed with
+// whichever signature version the client is configured for.
+func (req *request) signedURL(s3 *S3, expires time.Time) (*url.URL, error) {
+	switch s3.Signature {
+	case aws.V4Signature:
+		return signV4Query(s3, req, expires)
+	default:
+		return signV2Query(s3, req, expires)
+	}
+}
+
+// query runs the request and, on success, decodes the XML response body
+// into resp (if non-nil).
+func (s3 *S3) query(req *request, resp interface{}) error {
+	err := s3.prepare(req)
+	if err != nil {
+		return err
+	}
+	httpResp, err := s3.run(req, resp)
+	if err != nil {
+		return err
+	}
+	if httpResp != nil && httpResp.Body != nil {
+		httpResp.Body.Close()
+	}
+	return nil
+}
+
+// permanentRedirectEndpoint extracts the corrected endpoint from a 301
+// PermanentRedirect response body, or "" if none was present.
+func permanentRedirectEndpoint(r *http.Response) string {
+	var xmlErr xmlErrors
+	xml.NewDecoder(r.Body).Decode(&xmlErr)
+	if xmlErr.Endpoint == "" {
+		return ""
+	}
+	return "https://" + xmlErr.Endpoint
+}
+
+// run sends the request over HTTP, signing it first, and returns the raw
+// response for callers (such as GetReader) that need the body themselves.
+func (s3 *S3) run(req *request, resp interface{}) (*http.Response, error) {
+	u, err := req.url(s3.PathStyle)
+	if err != nil {
+		return nil, err
+	}
+
+	hreq := &http.Request{
+		Method:     req.method,
+		URL:        u,
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Close:      true,
+		Header:     req.headers,
+	}
+	if v := req.headers.Get("Content-Length"); v != "" {
+		hreq.ContentLength = parseInt64(v)
+	}
+	if req.payload != nil {
+		hreq.Body = io.NopCloser(req.payload)
+	}
+	// Ask for the stored bytes as-is: left unset, net/http would request
+	// gzip itself and silently decompress objects stored with
+	// Content-Encoding: gzip, such as those written by WithCompression.
+	if hreq.Header.Get("Accept-Encoding") == "" {
+		hreq.Header.Set("Accept-Encoding", "identity")
+	}
+	if req.ctx != nil {
+		hreq = hreq.WithContext(req.ctx)
+	}
+
+	switch s3.Signature {
+	case aws.V4Signature:
+		signV4(s3, hreq, req)
+	default:
+		signV2(s3, hreq, req)
+	}
+
+	httpResp, err := httpClient.Do(hreq)
+	if err != nil {
+		return nil, err
+	}
+	if httpResp.StatusCode == 301 && !req.redirected {
+		if endpoint := permanentRedirectEndpoint(httpResp); endpoint != "" {
+			httpResp.Body.Close()
+			req.redirected = true
+			req.baseurl = endpoint
+			req.prepared = true // baseurl is now final; don't let prepare recompute it.
+			return s3.run(req, resp)
+		}
+	}
+	if httpResp.StatusCode != 200 && httpResp.StatusCode != 204 && httpResp.StatusCode != 206 {
+		return nil, buildError(httpResp)
+	}
+	if resp != nil {
+		defer httpResp.Body.Close()
+		if err := xmlDecode(httpResp.Body, resp); err != nil {
+			return nil, err
+		}
+	}
+	return httpResp, nil
+}
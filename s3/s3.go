@@ -0,0 +1,617 @@
+// The s3 package provides a client for Amazon's S3 storage service and
+// S3-compatible implementations of it.
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/czos/goamz/aws"
+)
+
+// The S3 type encapsulates operations within a specific EC2 region.
+type S3 struct {
+	aws.Auth
+	aws.Region
+
+	// Signature selects which AWS request-signing algorithm is used.
+	// The zero value (aws.V2Signature) preserves the historical
+	// behaviour of this package.
+	Signature aws.SignatureVersion
+
+	// PathStyle forces path-style bucket addressing
+	// (https://host/bucket/key) instead of virtual-hosted-style
+	// (https://bucket.host/key). Required for S3-compatible servers
+	// that cannot obtain a wildcard certificate for every bucket.
+	PathStyle bool
+
+	// Compression, when set (normally via WithCompression rather than
+	// directly), makes Put/PutReader transparently compress payloads
+	// and Get/GetReader transparently decompress them.
+	Compression CompressionAlgo
+
+	private byte // Reserve the right of using private data.
+}
+
+// The Bucket type encapsulates operations with an S3 bucket.
+type Bucket struct {
+	*S3
+	Name string
+}
+
+// The Owner type represents the owner of the object in an S3 bucket.
+type Owner struct {
+	ID          string
+	DisplayName string
+}
+
+// New creates a new S3 object. The client's Signature defaults to
+// region.Sign, so a Region built with aws.NewRegion (which defaults to
+// SigV4) doesn't require the caller to also set Signature explicitly.
+func New(auth aws.Auth, region aws.Region) *S3 {
+	return &S3{Auth: auth, Region: region, Signature: region.Sign}
+}
+
+// Bucket returns a Bucket with the given name.
+func (s3 *S3) Bucket(name string) *Bucket {
+	if s3.Region.S3LowercaseBucket {
+		name = strings.ToLower(name)
+	}
+	return &Bucket{s3, name}
+}
+
+// locationConstraint contains the location constraint body used in PUT
+// Bucket requests when the region requires one.
+type locationConstraint struct {
+	LocationConstraint string
+}
+
+func (s3 *S3) locationConstraint() []byte {
+	constraint := locationConstraint{}
+	if s3.Region.S3LocationConstraint {
+		constraint.LocationConstraint = s3.Region.Name
+	}
+	data, _ := xml.Marshal(constraint)
+	return data
+}
+
+// ACL represents an S3 canned access control policy.
+type ACL string
+
+const (
+	Private           = ACL("private")
+	PublicRead        = ACL("public-read")
+	PublicReadWrite   = ACL("public-read-write")
+	AuthenticatedRead = ACL("authenticated-read")
+	BucketOwnerRead   = ACL("bucket-owner-read")
+	BucketOwnerFull   = ACL("bucket-owner-full-control")
+)
+
+// PutBucket creates a new bucket with the given ACL.
+func (b *Bucket) PutBucket(perm ACL) error {
+	headers := map[string][]string{
+		"x-amz-acl": {string(perm)},
+	}
+	req := &request{
+		method:  "PUT",
+		bucket:  b.Name,
+		path:    "/",
+		headers: headers,
+		payload: bytes.NewReader(b.locationConstraint()),
+	}
+	return b.S3.query(req, nil)
+}
+
+// DelBucket removes an existing S3 bucket. All objects in the bucket must
+// be removed before the bucket itself can be removed.
+func (b *Bucket) DelBucket() (err error) {
+	req := &request{
+		method: "DELETE",
+		bucket: b.Name,
+		path:   "/",
+	}
+	return b.S3.query(req, nil)
+}
+
+// sseByDefault is the body of a PutBucketEncryption request, which sets
+// the server-side encryption newly-PUT objects get by default if the PUT
+// itself doesn't specify one.
+type sseByDefault struct {
+	SSEAlgorithm   string `xml:"SSEAlgorithm"`
+	KMSMasterKeyID string `xml:"KMSMasterKeyID,omitempty"`
+}
+
+type serverSideEncryptionConfiguration struct {
+	XMLName xml.Name `xml:"ServerSideEncryptionConfiguration"`
+	Rule    struct {
+		ApplyServerSideEncryptionByDefault sseByDefault `xml:"ApplyServerSideEncryptionByDefault"`
+	} `xml:"Rule"`
+}
+
+// PutBucketEncryption sets the bucket's default server-side encryption,
+// applied to any object PUT into it that doesn't specify its own. algo is
+// "AES256" for SSE-S3 or "aws:kms" for SSE-KMS, in which case kmsKeyId may
+// name a customer master key (or be empty to use the account default).
+func (b *Bucket) PutBucketEncryption(algo, kmsKeyId string) error {
+	var config serverSideEncryptionConfiguration
+	config.Rule.ApplyServerSideEncryptionByDefault = sseByDefault{
+		SSEAlgorithm:   algo,
+		KMSMasterKeyID: kmsKeyId,
+	}
+	data, err := xml.Marshal(&config)
+	if err != nil {
+		return err
+	}
+	params := url.Values{"encryption": {""}}
+	req := &request{
+		method:  "PUT",
+		bucket:  b.Name,
+		path:    "/",
+		params:  params,
+		payload: bytes.NewReader(data),
+	}
+	return b.S3.query(req, nil)
+}
+
+// Get fetches the contents of an object.
+func (b *Bucket) Get(path string) (data []byte, err error) {
+	return b.GetWithContext(context.Background(), path)
+}
+
+// GetWithContext is like Get but aborts the request if ctx is cancelled
+// or its deadline expires before the response body is fully read.
+func (b *Bucket) GetWithContext(ctx context.Context, path string) (data []byte, err error) {
+	body, err := b.GetReaderWithContext(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return ioutil.ReadAll(body)
+}
+
+// GetReader retrieves an object from an S3 bucket. The caller must close
+// the returned ReadCloser when done reading.
+func (b *Bucket) GetReader(path string) (rc io.ReadCloser, err error) {
+	return b.GetReaderWithContext(context.Background(), path)
+}
+
+// GetReaderWithContext is like GetReader but ties the request, and any
+// subsequent reads from the returned ReadCloser, to ctx.
+func (b *Bucket) GetReaderWithContext(ctx context.Context, path string) (rc io.ReadCloser, err error) {
+	return b.getReader(ctx, path, Options{})
+}
+
+// GetWithOptions is like Get, but takes Options so the SSE-C customer key
+// headers an object was encrypted with can be resent; S3 requires them on
+// every read of such an object since it never stores the key itself.
+func (b *Bucket) GetWithOptions(path string, options Options) (data []byte, err error) {
+	body, err := b.GetReaderWithOptions(path, options)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return ioutil.ReadAll(body)
+}
+
+// GetReaderWithOptions is like GetReader, but takes Options so the SSE-C
+// customer key headers an object was encrypted with can be resent.
+func (b *Bucket) GetReaderWithOptions(path string, options Options) (rc io.ReadCloser, err error) {
+	return b.getReader(context.Background(), path, options)
+}
+
+func (b *Bucket) getReader(ctx context.Context, path string, options Options) (rc io.ReadCloser, err error) {
+	algo := b.S3.Compression
+	if algo != nil {
+		path += algo.suffix()
+	}
+	headers := map[string][]string{}
+	options.addSSEHeaders(headers)
+	req := &request{
+		method:  "GET",
+		bucket:  b.Name,
+		path:    path,
+		headers: headers,
+		ctx:     ctx,
+	}
+	err = b.S3.prepare(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.S3.run(req, nil)
+	if err != nil {
+		return nil, err
+	}
+	if algo == nil {
+		return resp.Body, nil
+	}
+	decompressed, err := algo.newReader(resp.Body)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return &compressedReadCloser{ReadCloser: decompressed, underlying: resp.Body}, nil
+}
+
+// Head represents the metadata returned by HeadObject.
+type Head struct {
+	ContentLength int64
+	ContentType   string
+	ETag          string
+	LastModified  string
+	Meta          map[string][]string
+}
+
+// HeadObject retrieves an object's metadata without fetching its body. For
+// an object encrypted with SSE-C, options must carry the same customer key
+// it was encrypted with.
+func (b *Bucket) HeadObject(path string, options Options) (*Head, error) {
+	if algo := b.S3.Compression; algo != nil {
+		path += algo.suffix()
+	}
+	headers := map[string][]string{}
+	options.addSSEHeaders(headers)
+	req := &request{
+		method:  "HEAD",
+		bucket:  b.Name,
+		path:    path,
+		headers: headers,
+	}
+	err := b.S3.prepare(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.S3.run(req, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	head := &Head{
+		ContentType:  resp.Header.Get("Content-Type"),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	if v := resp.Header.Get("Content-Length"); v != "" {
+		head.ContentLength = parseInt64(v)
+	}
+	for k, v := range resp.Header {
+		if strings.HasPrefix(strings.ToLower(k), "x-amz-meta-") {
+			if head.Meta == nil {
+				head.Meta = map[string][]string{}
+			}
+			head.Meta[k[len("x-amz-meta-"):]] = v
+		}
+	}
+	return head, nil
+}
+
+// Options holds per-object request options.
+type Options struct {
+	SSE                bool
+	Meta               map[string][]string
+	ContentEncoding    string
+	CacheControl       string
+	ContentMD5         string
+	ContentDisposition string
+
+	// ServerSideEncryption selects SSE-S3 ("AES256") or SSE-KMS
+	// ("aws:kms") encryption of the object at rest. Setting SSE is
+	// shorthand for ServerSideEncryption == "AES256".
+	ServerSideEncryption string
+	// SSEKMSKeyId names the customer master key to use with
+	// ServerSideEncryption == "aws:kms". If empty, S3 uses the account's
+	// default KMS master key.
+	SSEKMSKeyId string
+
+	// SSECustomerAlgorithm, SSECustomerKey and SSECustomerKeyMD5
+	// configure SSE-C, where the caller supplies its own encryption key
+	// with every request. SSECustomerAlgorithm is normally "AES256";
+	// SSECustomerKey is the base64-encoded 256-bit key and
+	// SSECustomerKeyMD5 the base64-encoded MD5 of that key, both of
+	// which S3 requires so it can verify the key without storing it.
+	// Unlike the other Options fields, these must also be resent on
+	// GetReader/HeadObject and on every PutPart of a multipart upload of
+	// an SSE-C object, since S3 never persists the key itself.
+	SSECustomerAlgorithm string
+	SSECustomerKey       string
+	SSECustomerKeyMD5    string
+
+	// Tagging sets the object's tags at PUT time, as "k1=v1&k2=v2"
+	// (URL-encoded key/value pairs, per x-amz-tagging). Prefer this over
+	// a separate PutObjectTagging call when the tags are known up front,
+	// since it avoids a second round trip.
+	Tagging string
+}
+
+// addSSEHeaders sets the subset of headers needed to request or satisfy
+// server-side encryption. It is split out from addHeaders because it's
+// also needed on requests, such as GetReader and PutPart, that don't
+// otherwise take a full Options.
+func (o Options) addSSEHeaders(headers map[string][]string) {
+	switch {
+	case o.SSE:
+		headers["x-amz-server-side-encryption"] = []string{"AES256"}
+	case o.ServerSideEncryption != "":
+		headers["x-amz-server-side-encryption"] = []string{o.ServerSideEncryption}
+	}
+	if o.SSEKMSKeyId != "" {
+		headers["x-amz-server-side-encryption-aws-kms-key-id"] = []string{o.SSEKMSKeyId}
+	}
+	if o.SSECustomerAlgorithm != "" {
+		headers["x-amz-server-side-encryption-customer-algorithm"] = []string{o.SSECustomerAlgorithm}
+		headers["x-amz-server-side-encryption-customer-key"] = []string{o.SSECustomerKey}
+		headers["x-amz-server-side-encryption-customer-key-MD5"] = []string{o.SSECustomerKeyMD5}
+	}
+}
+
+func (o Options) addHeaders(headers map[string][]string) {
+	o.addSSEHeaders(headers)
+	if o.ContentEncoding != "" {
+		headers["Content-Encoding"] = []string{o.ContentEncoding}
+	}
+	if o.CacheControl != "" {
+		headers["Cache-Control"] = []string{o.CacheControl}
+	}
+	if o.ContentMD5 != "" {
+		headers["Content-MD5"] = []string{o.ContentMD5}
+	}
+	if o.ContentDisposition != "" {
+		headers["Content-Disposition"] = []string{o.ContentDisposition}
+	}
+	if o.Tagging != "" {
+		headers["x-amz-tagging"] = []string{o.Tagging}
+	}
+	for k, v := range o.Meta {
+		headers["x-amz-meta-"+k] = v
+	}
+}
+
+// Put inserts an object into the bucket. Because the whole payload is
+// already in memory, it is hashed up front and sent with a regular
+// (non-streaming) SigV4 signature when the client uses aws.V4Signature.
+func (b *Bucket) Put(path string, data []byte, contType string, perm ACL, options Options) error {
+	return b.PutWithContext(context.Background(), path, data, contType, perm, options)
+}
+
+// PutWithContext is like Put but aborts the request if ctx is cancelled
+// or its deadline expires before the upload completes.
+func (b *Bucket) PutWithContext(ctx context.Context, path string, data []byte, contType string, perm ACL, options Options) error {
+	if algo := b.S3.Compression; algo != nil {
+		compressed, err := compressBytes(algo, data)
+		if err != nil {
+			return err
+		}
+		data = compressed
+		path += algo.suffix()
+		options.ContentEncoding = algo.contentEncoding()
+	}
+	headers := map[string][]string{
+		"Content-Length": {strconv.FormatInt(int64(len(data)), 10)},
+		"Content-Type":   {contType},
+		"x-amz-acl":      {string(perm)},
+	}
+	options.addHeaders(headers)
+	req := &request{
+		method:      "PUT",
+		bucket:      b.Name,
+		path:        path,
+		headers:     headers,
+		payload:     bytes.NewReader(data),
+		payloadHash: aws.Sha256Hex(data),
+		ctx:         ctx,
+	}
+	return b.S3.query(req, nil)
+}
+
+// PutReader inserts an object into the bucket by consuming data from r
+// until EOF. Unlike Put, the payload isn't available up front to hash,
+// so under aws.V4Signature it is streamed using chunked SigV4 signing
+// (STREAMING-AWS4-HMAC-SHA256-PAYLOAD) rather than buffered in memory.
+func (b *Bucket) PutReader(path string, r io.Reader, length int64, contType string, perm ACL, options Options) error {
+	return b.PutReaderWithContext(context.Background(), path, r, length, contType, perm, options)
+}
+
+// PutReaderWithContext is like PutReader but aborts the request, mid-
+// upload, if ctx is cancelled or its deadline expires.
+func (b *Bucket) PutReaderWithContext(ctx context.Context, path string, r io.Reader, length int64, contType string, perm ACL, options Options) error {
+	streamingV4 := b.S3.Signature == aws.V4Signature
+	if algo := b.S3.Compression; algo != nil {
+		path += algo.suffix()
+		options.ContentEncoding = algo.contentEncoding()
+		r = compressStream(algo, r)
+		length = -1 // compressed size isn't known up front.
+		// The STREAMING-AWS4-HMAC-SHA256-PAYLOAD mode signs over the
+		// declared decoded length, which we don't have here; fall back
+		// to an unsigned payload for this request rather than sign a
+		// length we can't guarantee.
+		streamingV4 = false
+	}
+	headers := map[string][]string{
+		"Content-Type": {contType},
+		"x-amz-acl":    {string(perm)},
+	}
+	if length >= 0 {
+		headers["Content-Length"] = []string{strconv.FormatInt(length, 10)}
+	}
+	options.addHeaders(headers)
+	req := &request{
+		method:      "PUT",
+		bucket:      b.Name,
+		path:        path,
+		headers:     headers,
+		payload:     r,
+		streamingV4: streamingV4,
+		ctx:         ctx,
+	}
+	return b.S3.query(req, nil)
+}
+
+// Del removes an object from the bucket.
+func (b *Bucket) Del(path string) error {
+	return b.DelWithContext(context.Background(), path)
+}
+
+// DelWithContext is like Del but aborts the request if ctx is cancelled
+// or its deadline expires.
+func (b *Bucket) DelWithContext(ctx context.Context, path string) error {
+	req := &request{
+		method: "DELETE",
+		bucket: b.Name,
+		path:   path,
+		ctx:    ctx,
+	}
+	return b.S3.query(req, nil)
+}
+
+// The ListResp type holds the results of a List bucket operation.
+type ListResp struct {
+	Name           string
+	Prefix         string
+	Delimiter      string
+	Marker         string
+	NextMarker     string
+	MaxKeys        int
+	IsTruncated    bool
+	Contents       []Key
+	CommonPrefixes []string `xml:">Prefix"`
+}
+
+// The Key type represents an item stored in an S3 bucket.
+type Key struct {
+	Key          string
+	LastModified string
+	Size         int64
+	// ETag is given in its quoted form, as returned by S3.
+	ETag         string
+	StorageClass string
+	Owner        Owner
+}
+
+// List lists objects (up to 1000) in a bucket, optionally filtered by
+// prefix, delimiter and marker, as described in the S3 documentation.
+func (b *Bucket) List(prefix, delim, marker string, max int) (result *ListResp, err error) {
+	return b.ListWithContext(context.Background(), prefix, delim, marker, max)
+}
+
+// ListWithContext is like List but aborts the request, including any
+// retries, as soon as ctx is cancelled or its deadline expires.
+func (b *Bucket) ListWithContext(ctx context.Context, prefix, delim, marker string, max int) (result *ListResp, err error) {
+	params := url.Values{}
+	params.Set("prefix", prefix)
+	params.Set("delimiter", delim)
+	params.Set("marker", marker)
+	if max != 0 {
+		params.Set("max-keys", strconv.FormatInt(int64(max), 10))
+	}
+	req := &request{
+		method: "GET",
+		bucket: b.Name,
+		path:   "/",
+		params: params,
+		ctx:    ctx,
+	}
+	result = &ListResp{}
+	for attempt := attempts.Start(); attempt.NextContext(ctx); {
+		err = b.S3.query(req, result)
+		if !shouldRetry(err) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// URL returns a non-signed URL that allows retrieving the object at path
+// via a GET request, if it's public.
+func (b *Bucket) URL(path string) string {
+	req := &request{
+		bucket: b.Name,
+		path:   path,
+	}
+	err := b.S3.prepare(req)
+	if err != nil {
+		panic(err)
+	}
+	u, err := req.url(b.S3.PathStyle)
+	if err != nil {
+		panic(err)
+	}
+	u.RawQuery = ""
+	return u.String()
+}
+
+// SignedURL returns a signed URL that allows anyone holding the URL to
+// retrieve the object at path until expires.
+func (b *Bucket) SignedURL(path string, expires time.Time) string {
+	req := &request{
+		bucket: b.Name,
+		path:   path,
+	}
+	err := b.S3.prepare(req)
+	if err != nil {
+		panic(err)
+	}
+	u, err := req.signedURL(b.S3, expires)
+	if err != nil {
+		panic(err)
+	}
+	return u.String()
+}
+
+// Error represents an error in an operation with S3.
+type Error struct {
+	StatusCode int    // HTTP status code (200, 403, ...)
+	Code       string // EC2 error code ("UnsupportedOperation", ...)
+	Message    string // The human-oriented error message
+	BucketName string
+	RequestId  string
+	HostId     string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+type xmlErrors struct {
+	RequestId  string `xml:"RequestId"`
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+	BucketName string `xml:"BucketName"`
+	HostId     string `xml:"HostId"`
+	// Endpoint is only populated on a 301 PermanentRedirect response,
+	// and names the correct endpoint to retry the request against.
+	Endpoint string `xml:"Endpoint"`
+}
+
+func shouldRetry(err error) bool {
+	if err == nil {
+		return false
+	}
+	if e, ok := err.(*Error); ok {
+		return e.Code == "InternalError"
+	}
+	return false
+}
+
+func buildError(r *http.Response) error {
+	var xmlErr xmlErrors
+	xml.NewDecoder(r.Body).Decode(&xmlErr)
+	r.Body.Close()
+	return &Error{
+		StatusCode: r.StatusCode,
+		Code:       xmlErr.Code,
+		Message:    xmlErr.Message,
+		BucketName: xmlErr.BucketName,
+		RequestId:  xmlErr.RequestId,
+		HostId:     xmlErr.HostId,
+	}
+}
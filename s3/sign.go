@@ -0,0 +1,154 @@
+package s3
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// subResources are the query string parameters that must be included in
+// the V2 string to sign when present, per the S3 REST authentication
+// documentation.
+var subResources = map[string]bool{
+	"acl":                          true,
+	"lifecycle":                    true,
+	"location":                     true,
+	"logging":                      true,
+	"notification":                 true,
+	"partNumber":                   true,
+	"policy":                       true,
+	"requestPayment":               true,
+	"torrent":                      true,
+	"uploadId":                     true,
+	"uploads":                      true,
+	"versionId":                    true,
+	"versioning":                   true,
+	"versions":                     true,
+	"response-content-type":        true,
+	"response-content-language":    true,
+	"response-expires":             true,
+	"response-cache-control":       true,
+	"response-content-disposition": true,
+	"response-content-encoding":    true,
+	"delete":                       true,
+	"encryption":                   true,
+	"tagging":                      true,
+	"website":                      true,
+}
+
+// signV2 implements the classic AWS S3 "Signature Version 2" scheme and
+// sets the resulting Authorization header on hreq.
+func signV2(s3 *S3, hreq *http.Request, req *request) {
+	if s3.Auth.Token != "" {
+		hreq.Header.Set("x-amz-security-token", s3.Auth.Token)
+	}
+	hreq.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+
+	sts := stringToSignV2(hreq.Method, req.bucket, req.signpath, hreq.Header, req.params)
+	h := hmac.New(sha1.New, []byte(s3.Auth.SecretKey))
+	h.Write([]byte(sts))
+	signature := base64.StdEncoding.EncodeToString(h.Sum(nil))
+
+	hreq.Header.Set("Authorization", "AWS "+s3.Auth.AccessKey+":"+signature)
+}
+
+func stringToSignV2(method, bucket, path string, headers http.Header, params url.Values) string {
+	var md5, ctype, date, xamz string
+	var xamzHeaders []string
+	for k, v := range headers {
+		lk := strings.ToLower(k)
+		switch {
+		case lk == "content-md5":
+			md5 = v[0]
+		case lk == "content-type":
+			ctype = v[0]
+		case lk == "date":
+			date = v[0]
+		case strings.HasPrefix(lk, "x-amz-"):
+			xamzHeaders = append(xamzHeaders, lk+":"+strings.Join(v, ","))
+		}
+	}
+	if len(xamzHeaders) > 0 {
+		sort.Strings(xamzHeaders)
+		xamz = strings.Join(xamzHeaders, "\n") + "\n"
+	}
+
+	var buf strings.Builder
+	buf.WriteString(method)
+	buf.WriteByte('\n')
+	buf.WriteString(md5)
+	buf.WriteByte('\n')
+	buf.WriteString(ctype)
+	buf.WriteByte('\n')
+	buf.WriteString(date)
+	buf.WriteByte('\n')
+	buf.WriteString(xamz)
+	buf.WriteString(canonicalResourceV2(bucket, path, params))
+	return buf.String()
+}
+
+func canonicalResourceV2(bucket, path string, params url.Values) string {
+	var resource strings.Builder
+	if bucket != "" {
+		resource.WriteByte('/')
+		resource.WriteString(bucket)
+	}
+	resource.WriteString(path)
+
+	var keys []string
+	for k := range params {
+		if subResources[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		if i == 0 {
+			resource.WriteByte('?')
+		} else {
+			resource.WriteByte('&')
+		}
+		resource.WriteString(k)
+		if v := params.Get(k); v != "" {
+			resource.WriteByte('=')
+			resource.WriteString(v)
+		}
+	}
+	return resource.String()
+}
+
+// signV2Query returns a pre-signed URL using Signature Version 2's
+// query-string signing scheme.
+func signV2Query(s3 *S3, req *request, expires time.Time) (*url.URL, error) {
+	u, err := req.url(s3.PathStyle)
+	if err != nil {
+		return nil, err
+	}
+	expiresStr := formatExpires(expires)
+	headers := http.Header{"Date": {expiresStr}}
+	sts := stringToSignV2("GET", req.bucket, req.signpath, headers, req.params)
+
+	h := hmac.New(sha1.New, []byte(s3.Auth.SecretKey))
+	h.Write([]byte(sts))
+	signature := base64.StdEncoding.EncodeToString(h.Sum(nil))
+
+	q := u.Query()
+	q.Set("AWSAccessKeyId", s3.Auth.AccessKey)
+	q.Set("Expires", expiresStr)
+	q.Set("Signature", signature)
+	if s3.Auth.Token != "" {
+		q.Set("x-amz-security-token", s3.Auth.Token)
+	}
+	u.RawQuery = q.Encode()
+	return u, nil
+}
+
+func formatExpires(expires time.Time) string {
+	return strconv.FormatInt(expires.Unix(), 10)
+}
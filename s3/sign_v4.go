@@ -0,0 +1,213 @@
+package s3
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/czos/goamz/aws"
+)
+
+// v4Service is the SigV4 service name S3 signs under.
+const v4Service = "s3"
+
+// signV4 signs hreq in place using AWS Signature Version 4, setting the
+// Authorization header. When the caller sets req.streamingV4 for a body
+// it didn't buffer up front, it uses the chunked streaming mode
+// (STREAMING-AWS4-HMAC-SHA256-PAYLOAD) so the caller doesn't have to read
+// the whole body into memory just to hash it. A body with neither
+// streamingV4 nor a payloadHash is signed as UNSIGNED-PAYLOAD.
+func signV4(s3 *S3, hreq *http.Request, req *request) {
+	now := aws.Now().UTC()
+	amzDate := now.Format(aws.ISO8601BasicFormat)
+	shortDate := now.Format(aws.ISO8601BasicFormatShort)
+
+	hreq.Header.Set("x-amz-date", amzDate)
+	hreq.Header.Set("Host", hreq.URL.Host)
+	if s3.Auth.Token != "" {
+		hreq.Header.Set("x-amz-security-token", s3.Auth.Token)
+	}
+
+	streaming := req.streamingV4 && hreq.Body != nil
+	hashedPayload := aws.UnsignedPayload
+	switch {
+	case streaming:
+		hashedPayload = aws.StreamingPayload
+		hreq.Header.Set("x-amz-content-sha256", hashedPayload)
+		hreq.Header.Set("x-amz-decoded-content-length", strconv.FormatInt(hreq.ContentLength, 10))
+		hreq.Header.Del("Content-Length")
+		hreq.TransferEncoding = []string{"chunked"}
+	case hreq.Body != nil && req.payloadHash != "":
+		hashedPayload = req.payloadHash
+		hreq.Header.Set("x-amz-content-sha256", hashedPayload)
+	default:
+		hreq.Header.Set("x-amz-content-sha256", hashedPayload)
+	}
+
+	headers, signedHeaders := canonicalHeaders(hreq.Header)
+	canonicalReq := aws.CanonicalRequest(hreq.Method, hreq.URL.Path, aws.CanonicalQueryString(hreq.URL.Query()), headers, signedHeaders, hashedPayload)
+	scope := aws.CredentialScope(shortDate, s3.Region.Name, v4Service)
+	sts := aws.StringToSign(amzDate, scope, canonicalReq)
+	signature := aws.SignV4(s3.Auth.SecretKey, shortDate, s3.Region.Name, v4Service, sts)
+
+	authHeader := "AWS4-HMAC-SHA256 Credential=" + s3.Auth.AccessKey + "/" + scope +
+		", SignedHeaders=" + strings.Join(signedHeaders, ";") +
+		", Signature=" + signature
+	hreq.Header.Set("Authorization", authHeader)
+
+	if streaming {
+		hreq.Body = newChunkedReader(hreq.Body, s3.Auth.SecretKey, shortDate, s3.Region.Name, signature, now)
+	}
+}
+
+// signV4Query returns a pre-signed URL using SigV4's query-string
+// signing scheme (X-Amz-Signature et al).
+func signV4Query(s3 *S3, req *request, expires time.Time) (*url.URL, error) {
+	u, err := req.url(s3.PathStyle)
+	if err != nil {
+		return nil, err
+	}
+	now := aws.Now().UTC()
+	amzDate := now.Format(aws.ISO8601BasicFormat)
+	shortDate := now.Format(aws.ISO8601BasicFormatShort)
+	scope := aws.CredentialScope(shortDate, s3.Region.Name, v4Service)
+
+	q := u.Query()
+	q.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	q.Set("X-Amz-Credential", s3.Auth.AccessKey+"/"+scope)
+	q.Set("X-Amz-Date", amzDate)
+	q.Set("X-Amz-Expires", strconv.FormatInt(int64(expires.Sub(now).Seconds()), 10))
+	q.Set("X-Amz-SignedHeaders", "host")
+	if s3.Auth.Token != "" {
+		q.Set("X-Amz-Security-Token", s3.Auth.Token)
+	}
+	u.RawQuery = q.Encode()
+
+	headers := map[string]string{"host": u.Host}
+	canonicalReq := aws.CanonicalRequest("GET", u.Path, aws.CanonicalQueryString(u.Query()), headers, []string{"host"}, aws.UnsignedPayload)
+	sts := aws.StringToSign(amzDate, scope, canonicalReq)
+	signature := aws.SignV4(s3.Auth.SecretKey, shortDate, s3.Region.Name, v4Service, sts)
+
+	q.Set("X-Amz-Signature", signature)
+	u.RawQuery = q.Encode()
+	return u, nil
+}
+
+// canonicalHeaders returns the lowercase header map and sorted list of
+// signed header names SigV4 requires.
+func canonicalHeaders(h http.Header) (map[string]string, []string) {
+	headers := map[string]string{}
+	var signed []string
+	for k, v := range h {
+		lk := strings.ToLower(k)
+		if lk != "host" && !strings.HasPrefix(lk, "x-amz-") && lk != "content-type" {
+			continue
+		}
+		headers[lk] = strings.Join(v, ",")
+		signed = append(signed, lk)
+	}
+	return headers, signed
+}
+
+// chunkedReader wraps a request body and emits it in SigV4's chunked
+// streaming format, signing each chunk against the previous chunk's
+// signature so the overall payload never has to be buffered to compute a
+// single upfront sha256.
+type chunkedReader struct {
+	r             io.ReadCloser
+	secretKey     string
+	date, region  string
+	prevSignature string
+	now           time.Time
+	buf           []byte
+	eof           bool // the underlying reader has been fully drained
+	done          bool // the terminating zero-length chunk has been emitted
+}
+
+const chunkSize = 64 * 1024
+
+func newChunkedReader(r io.ReadCloser, secretKey, date, region, seedSignature string, now time.Time) io.ReadCloser {
+	return &chunkedReader{
+		r:             r,
+		secretKey:     secretKey,
+		date:          date,
+		region:        region,
+		prevSignature: seedSignature,
+		now:           now,
+	}
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	if len(c.buf) == 0 {
+		switch {
+		case c.done:
+			return 0, io.EOF
+		case c.eof:
+			// The underlying body is drained; every streaming payload,
+			// regardless of size, must end with one mandatory
+			// zero-length chunk.
+			c.buf = c.encodeChunk(nil)
+			c.done = true
+		default:
+			chunk := make([]byte, chunkSize)
+			n, err := io.ReadFull(c.r, chunk)
+			chunk = chunk[:n]
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				c.eof = true
+			} else if err != nil {
+				return 0, err
+			}
+			if n > 0 {
+				c.buf = c.encodeChunk(chunk)
+			} else {
+				// The body ended exactly on a chunkSize boundary: fold
+				// straight into the terminator instead of emitting a
+				// spurious empty data chunk first.
+				c.buf = c.encodeChunk(nil)
+				c.done = true
+			}
+		}
+	}
+	n := copy(p, c.buf)
+	c.buf = c.buf[n:]
+	return n, nil
+}
+
+func (c *chunkedReader) Close() error {
+	return c.r.Close()
+}
+
+// encodeChunk wraps data in the "<hex-size>;chunk-signature=<sig>\r\n<data>\r\n"
+// framing the streaming payload mode requires, chaining each chunk's
+// signature off the previous one.
+func (c *chunkedReader) encodeChunk(data []byte) []byte {
+	amzDate := c.now.Format(aws.ISO8601BasicFormat)
+	scope := aws.CredentialScope(c.date, c.region, v4Service)
+	sum := sha256.Sum256(data)
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256-PAYLOAD",
+		amzDate,
+		scope,
+		c.prevSignature,
+		emptyStringHash,
+		hex.EncodeToString(sum[:]),
+	}, "\n")
+	signature := aws.SignV4(c.secretKey, c.date, c.region, v4Service, stringToSign)
+	c.prevSignature = signature
+
+	var out strings.Builder
+	out.WriteString(strconv.FormatInt(int64(len(data)), 16))
+	out.WriteString(";chunk-signature=")
+	out.WriteString(signature)
+	out.WriteString("\r\n")
+	out.Write(data)
+	out.WriteString("\r\n")
+	return []byte(out.String())
+}
+
+var emptyStringHash = aws.Sha256Hex(nil)
@@ -0,0 +1,75 @@
+package s3
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/czos/goamz/aws"
+)
+
+// decodeAWSChunked reverses the aws-chunked framing chunkedReader produces,
+// returning the concatenated chunk data. It fails the test if the framing
+// is malformed or isn't properly terminated by a single zero-length chunk.
+func decodeAWSChunked(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var out []byte
+	for {
+		i := bytes.Index(data, []byte("\r\n"))
+		if i < 0 {
+			t.Fatalf("malformed chunk: missing header CRLF in %q", data)
+		}
+		header := string(data[:i])
+		data = data[i+2:]
+
+		semi := strings.Index(header, ";")
+		if semi < 0 || !strings.HasPrefix(header[semi+1:], "chunk-signature=") {
+			t.Fatalf("malformed chunk header %q", header)
+		}
+		size, err := strconv.ParseInt(header[:semi], 16, 64)
+		if err != nil {
+			t.Fatalf("bad chunk size in header %q: %v", header, err)
+		}
+
+		if int64(len(data)) < size+2 {
+			t.Fatalf("truncated chunk body: want %d bytes + CRLF, have %d", size, len(data))
+		}
+		out = append(out, data[:size]...)
+		if string(data[size:size+2]) != "\r\n" {
+			t.Fatalf("chunk of size %d not terminated by CRLF", size)
+		}
+		data = data[size+2:]
+
+		if size == 0 {
+			if len(data) != 0 {
+				t.Fatalf("trailing data after terminating chunk: %q", data)
+			}
+			break
+		}
+	}
+	return out
+}
+
+// TestChunkedReaderTerminator guards against a streaming encoder that only
+// emits the mandatory terminating zero-length chunk when the body length
+// happens to be an exact multiple of chunkSize.
+func TestChunkedReaderTerminator(t *testing.T) {
+	sizes := []int{0, 1, chunkSize - 1, chunkSize, chunkSize + 1, 2 * chunkSize, 2*chunkSize + 123}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	for _, size := range sizes {
+		data := bytes.Repeat([]byte("a"), size)
+		r := newChunkedReader(ioutil.NopCloser(bytes.NewReader(data)), "secret", "20150830", "us-east-1", aws.Sha256Hex(nil), now)
+
+		encoded, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatalf("size %d: ReadAll: %v", size, err)
+		}
+		got := decodeAWSChunked(t, encoded)
+		if !bytes.Equal(got, data) {
+			t.Fatalf("size %d: decoded data mismatch: got %d bytes, want %d", size, len(got), len(data))
+		}
+	}
+}
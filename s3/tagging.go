@@ -0,0 +1,138 @@
+package s3
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/url"
+	"sort"
+)
+
+// tagging is the <Tagging><TagSet> document S3 uses for both the bucket
+// and the per-object tagging subresource.
+type tagging struct {
+	XMLName xml.Name `xml:"Tagging"`
+	Tags    []tagXML `xml:"TagSet>Tag"`
+}
+
+type tagXML struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+// marshalTagging encodes tags in a deterministic (key-sorted) order so
+// that otherwise-identical tag sets produce identical request bodies.
+func marshalTagging(tags map[string]string) ([]byte, error) {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	doc := tagging{}
+	for _, k := range keys {
+		doc.Tags = append(doc.Tags, tagXML{Key: k, Value: tags[k]})
+	}
+	return xml.Marshal(&doc)
+}
+
+func (doc tagging) toMap() map[string]string {
+	tags := make(map[string]string, len(doc.Tags))
+	for _, t := range doc.Tags {
+		tags[t.Key] = t.Value
+	}
+	return tags
+}
+
+// tagParams builds the "tagging" subresource query string shared by the
+// bucket- and object-level tagging requests below.
+func tagParams() url.Values {
+	return url.Values{"tagging": {""}}
+}
+
+// PutBucketTagging replaces the bucket's tag set.
+func (b *Bucket) PutBucketTagging(tags map[string]string) error {
+	data, err := marshalTagging(tags)
+	if err != nil {
+		return err
+	}
+	req := &request{
+		method:  "PUT",
+		bucket:  b.Name,
+		path:    "/",
+		params:  tagParams(),
+		payload: bytes.NewReader(data),
+	}
+	return b.S3.query(req, nil)
+}
+
+// GetBucketTagging returns the bucket's current tag set, which is empty
+// (not an error) for a bucket without tags.
+func (b *Bucket) GetBucketTagging() (map[string]string, error) {
+	req := &request{
+		method: "GET",
+		bucket: b.Name,
+		path:   "/",
+		params: tagParams(),
+	}
+	var doc tagging
+	if err := b.S3.query(req, &doc); err != nil {
+		// S3 reports a bucket with no tags as a NoSuchTagSet error.
+		if e, ok := err.(*Error); ok && e.Code == "NoSuchTagSet" {
+			return map[string]string{}, nil
+		}
+		return nil, err
+	}
+	return doc.toMap(), nil
+}
+
+// DeleteBucketTagging removes all tags from the bucket.
+func (b *Bucket) DeleteBucketTagging() error {
+	req := &request{
+		method: "DELETE",
+		bucket: b.Name,
+		path:   "/",
+		params: tagParams(),
+	}
+	return b.S3.query(req, nil)
+}
+
+// PutObjectTagging replaces the tag set of the object at path.
+func (b *Bucket) PutObjectTagging(path string, tags map[string]string) error {
+	data, err := marshalTagging(tags)
+	if err != nil {
+		return err
+	}
+	req := &request{
+		method:  "PUT",
+		bucket:  b.Name,
+		path:    path,
+		params:  tagParams(),
+		payload: bytes.NewReader(data),
+	}
+	return b.S3.query(req, nil)
+}
+
+// GetObjectTagging returns the current tag set of the object at path.
+func (b *Bucket) GetObjectTagging(path string) (map[string]string, error) {
+	req := &request{
+		method: "GET",
+		bucket: b.Name,
+		path:   path,
+		params: tagParams(),
+	}
+	var doc tagging
+	if err := b.S3.query(req, &doc); err != nil {
+		return nil, err
+	}
+	return doc.toMap(), nil
+}
+
+// DeleteObjectTagging removes all tags from the object at path.
+func (b *Bucket) DeleteObjectTagging(path string) error {
+	req := &request{
+		method: "DELETE",
+		bucket: b.Name,
+		path:   path,
+		params: tagParams(),
+	}
+	return b.S3.query(req, nil)
+}
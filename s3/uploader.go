@@ -0,0 +1,256 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// minUploaderPartSize and maxUploaderParts mirror the limits S3 itself
+// imposes on multipart uploads: no part (other than the last) may be
+// smaller than 5MB, and no upload may have more than 10,000 parts.
+const (
+	minUploaderPartSize = 5 * 1024 * 1024
+	maxUploaderParts    = 10000
+)
+
+// Uploader drives a multipart upload of a large object with bounded
+// concurrency, automatic part sizing, and per-part retries. It exists
+// for the common "upload a big object" case that Multi.PutAll handles
+// only sequentially, one part at a time.
+type Uploader struct {
+	Bucket   *Bucket
+	Key      string
+	ContType string
+	Perm     ACL
+
+	// Concurrency is the number of parts uploaded in parallel. It
+	// defaults to 4 if zero or negative.
+	Concurrency int
+
+	// PartSize is the minimum part size requested; it is rounded up
+	// (doubling) as necessary to keep the part count under S3's
+	// 10,000-part limit. Any value below the 5MB minimum S3 itself
+	// requires, including zero or negative, is raised to that minimum.
+	PartSize int64
+
+	// Progress, if set, is called after each part this call uploads
+	// completes, with the total number of bytes uploaded so far (counting
+	// parts a Resume call found already done) and the overall upload
+	// size. It is not called for the already-done parts themselves.
+	Progress func(uploaded, total int64)
+
+	// Options is passed to InitMulti, most notably for server-side
+	// encryption: an SSE-C customer key set here is automatically
+	// resent on every part PutPart uploads.
+	Options Options
+}
+
+// NewUploader creates an Uploader that will upload to the given bucket
+// and key with the given content type and ACL.
+func NewUploader(b *Bucket, key, contType string, perm ACL) *Uploader {
+	return &Uploader{Bucket: b, Key: key, ContType: contType, Perm: perm}
+}
+
+// Upload starts a new multipart upload and uploads r (an io.ReaderAt of
+// the given size, so parts can be read concurrently and out of order)
+// to it. On success it returns the upload ID and the completed parts,
+// ready to be passed to Multi.Complete. On failure it returns the same,
+// for whatever parts had completed, so the caller can retry the rest
+// later via Resume.
+func (u *Uploader) Upload(ctx context.Context, r io.ReaderAt, size int64) (uploadId string, parts []Part, err error) {
+	multi, err := u.Bucket.initMulti(ctx, u.Key, u.ContType, u.Perm, u.Options)
+	if err != nil {
+		return "", nil, err
+	}
+	parts, err = u.run(ctx, multi, nil, r, size)
+	return multi.UploadId, parts, err
+}
+
+// Resume continues a multipart upload that a previous call to Upload
+// (or Resume) reported as failed, given its upload ID and the parts it
+// had already completed. It first re-issues ListParts to reconcile
+// server-side state -- a part may have actually completed even though
+// the client gave up waiting for it -- before uploading whatever
+// remains.
+func (u *Uploader) Resume(ctx context.Context, uploadId string, completed []Part, r io.ReaderAt, size int64) ([]Part, error) {
+	multi := &Multi{Bucket: u.Bucket, Key: u.Key, UploadId: uploadId, Options: u.Options}
+	serverParts, err := multi.ListParts()
+	if err != nil {
+		return nil, err
+	}
+
+	have := make(map[int]Part, len(serverParts)+len(completed))
+	for _, p := range serverParts {
+		have[p.N] = p
+	}
+	for _, p := range completed {
+		have[p.N] = p
+	}
+	return u.run(ctx, multi, have, r, size)
+}
+
+// run uploads whichever of size's parts aren't already in done,
+// across u.concurrency() workers, and returns the full set of completed
+// parts in part-number order.
+func (u *Uploader) run(ctx context.Context, multi *Multi, done map[int]Part, r io.ReaderAt, size int64) ([]Part, error) {
+	partSize := u.partSize(size)
+	numParts := int((size + partSize - 1) / partSize)
+	if numParts == 0 {
+		numParts = 1 // S3 requires at least one part, even an empty one.
+	}
+	if done == nil {
+		done = make(map[int]Part, numParts)
+	}
+
+	type job struct {
+		n              int
+		offset, length int64
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan job)
+	results := make(chan Part)
+	errs := make(chan error, 1)
+
+	var wg sync.WaitGroup
+	for i := 0; i < u.concurrency(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				part, err := u.putPart(ctx, multi, j.n, r, j.offset, j.length)
+				if err != nil {
+					select {
+					case errs <- err:
+						cancel()
+					default:
+					}
+					return
+				}
+				select {
+				case results <- part:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for n := 1; n <= numParts; n++ {
+			if _, ok := done[n]; ok {
+				continue
+			}
+			offset := int64(n-1) * partSize
+			length := partSize
+			if offset+length > size {
+				length = size - offset
+			}
+			select {
+			case jobs <- job{n, offset, length}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var uploaded int64
+	for _, p := range done {
+		uploaded += p.Size
+	}
+	remaining := numParts - len(done)
+
+	var runErr error
+loop:
+	for i := 0; i < remaining; i++ {
+		select {
+		case part := <-results:
+			done[part.N] = part
+			uploaded += part.Size
+			if u.Progress != nil {
+				u.Progress(uploaded, size)
+			}
+		case runErr = <-errs:
+			break loop
+		}
+	}
+	wg.Wait()
+	if runErr == nil {
+		select {
+		case runErr = <-errs:
+		default:
+		}
+	}
+
+	return sortedParts(done), runErr
+}
+
+// putPart reads the part's bytes via ReadAt, uploads them with a
+// per-part retry loop, and verifies S3's returned ETag against the
+// locally computed MD5 before accepting the part as done.
+func (u *Uploader) putPart(ctx context.Context, multi *Multi, n int, r io.ReaderAt, offset, length int64) (Part, error) {
+	data := make([]byte, length)
+	if _, err := r.ReadAt(data, offset); err != nil && err != io.EOF {
+		return Part{}, err
+	}
+	sum := md5.Sum(data)
+	expected := hex.EncodeToString(sum[:])
+
+	var part Part
+	var err error
+	for attempt := attempts.Start(); attempt.NextContext(ctx); {
+		part, err = multi.PutPartWithContext(ctx, n, bytes.NewReader(data))
+		if err == nil {
+			if got := strings.Trim(part.ETag, `"`); got != expected {
+				err = fmt.Errorf("s3: uploader: part %d ETag mismatch: want %s, got %s", n, expected, got)
+			} else {
+				return part, nil
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if err == nil {
+		// ctx was done before the first attempt, so nothing was sent.
+		err = ctx.Err()
+	}
+	return Part{}, err
+}
+
+func (u *Uploader) partSize(size int64) int64 {
+	partSize := u.PartSize
+	if partSize < minUploaderPartSize {
+		partSize = minUploaderPartSize
+	}
+	for size/partSize >= maxUploaderParts {
+		partSize *= 2
+	}
+	return partSize
+}
+
+func (u *Uploader) concurrency() int {
+	if u.Concurrency <= 0 {
+		return 4
+	}
+	return u.Concurrency
+}
+
+func sortedParts(m map[int]Part) []Part {
+	parts := make(partList, 0, len(m))
+	for _, p := range m {
+		parts = append(parts, p)
+	}
+	sort.Sort(parts)
+	return []Part(parts)
+}
@@ -0,0 +1,16 @@
+package s3
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+)
+
+func parseInt64(s string) int64 {
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
+
+func xmlDecode(r io.Reader, into interface{}) error {
+	return xml.NewDecoder(r).Decode(into)
+}
@@ -0,0 +1,13 @@
+// Package testutil provides common helpers used by goamz's test suites.
+package testutil
+
+import "flag"
+
+// Amazon, when true, enables tests that talk to live AWS endpoints
+// rather than a local mock server. It is controlled by the -amazon flag
+// so that the default `go test` run never makes real network calls.
+var Amazon bool
+
+func init() {
+	flag.BoolVar(&Amazon, "amazon", false, "Enable tests against live Amazon AWS servers")
+}